@@ -0,0 +1,236 @@
+package clientpool
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+const (
+	// EnableClientPoolEnvVar turns the shared client pool on/off; set to "false" to
+	// fall back to one Sarama client per subscription if the pool is suspected of
+	// causing an incident.
+	EnableClientPoolEnvVar = "ENABLE_SARAMA_CLIENT_POOL"
+
+	// EnableLoggerEnvVar wires Sarama's own informational logging to the dispatcher's
+	// logger when set to "true".
+	EnableLoggerEnvVar = "ENABLE_SARAMA_LOGGER"
+
+	// EnableDebugLoggerEnvVar wires Sarama's verbose debug logging to the dispatcher's
+	// logger when set to "true", for use during incident response.
+	EnableDebugLoggerEnvVar = "ENABLE_SARAMA_DEBUG_LOGGER"
+)
+
+// Key uniquely identifies the connection details of a Sarama client, so that
+// subscriptions which share the same brokers, SASL identity, and TLS config
+// can be served by a single pooled client instead of each opening their own.
+type Key struct {
+	Brokers  string
+	SASLUser string
+	TLSHash  string
+}
+
+// NewKey builds a Key from the connection details used to dial a Kafka cluster.
+func NewKey(brokers []string, saslUser string, tlsConfig *tls.Config) Key {
+	return Key{
+		Brokers:  strings.Join(brokers, ","),
+		SASLUser: saslUser,
+		TLSHash:  hashTLSConfig(tlsConfig),
+	}
+}
+
+// hashTLSConfig reduces a *tls.Config to a comparable digest so it can be
+// folded into a Key without requiring tls.Config itself to be comparable.
+func hashTLSConfig(tlsConfig *tls.Config) string {
+	if tlsConfig == nil {
+		return ""
+	}
+
+	hasher := sha256.New()
+	for _, cert := range tlsConfig.Certificates {
+		for _, der := range cert.Certificate {
+			hasher.Write(der)
+		}
+	}
+	fmt.Fprintf(hasher, "%t", tlsConfig.InsecureSkipVerify)
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// pooledClient is the subset of sarama.Client that the Pool's own ref-counting
+// logic relies on. Entries are kept against this narrower interface, rather
+// than the full sarama.Client, purely so acquire/Release can be unit tested
+// against a fake that only needs a Close method, without a live broker to
+// dial or a fake implementing sarama.Client's entire (broker-dialing) method
+// set.
+type pooledClient interface {
+	Close() error
+}
+
+// entry is a single pooled Sarama client along with how many callers are
+// currently relying on it.
+type entry struct {
+	client   pooledClient
+	refCount int
+}
+
+// newSaramaClient dials a Sarama client; overridden in tests so the Pool's
+// ref-counting can be exercised without a live broker.
+var newSaramaClient = func(brokers []string, config *sarama.Config) (pooledClient, error) {
+	return sarama.NewClient(brokers, config)
+}
+
+// Pool reference-counts Sarama clients keyed by (brokers, SASL user, TLS config),
+// so many dispatcher subscriptions against the same cluster share one set of TCP
+// connections and one metadata refresh loop instead of duplicating both per
+// subscription. Every Get* call supplies the *sarama.Config to dial with, so a
+// single Pool can correctly serve multiple distinct credential/TLS sets - the
+// config used to dial is whichever caller is first to reference a given Key.
+type Pool struct {
+	logger  *zap.Logger
+	mutex   sync.Mutex
+	entries map[Key]*entry
+}
+
+// NewPool constructs an empty client Pool.
+func NewPool(logger *zap.Logger) *Pool {
+	return &Pool{
+		logger:  logger,
+		entries: make(map[Key]*entry),
+	}
+}
+
+// GetClient returns the pooled sarama.Client for key directly, dialing
+// brokers with config and adding key to the pool on first use. Used by
+// callers that need Kafka metadata (e.g. partition high-water marks) rather
+// than a ClusterAdmin/ConsumerGroup/Producer built on top of one.
+func (p *Pool) GetClient(brokers []string, key Key, config *sarama.Config) (sarama.Client, error) {
+	client, err := p.acquire(brokers, key, config)
+	if err != nil {
+		return nil, err
+	}
+	return client.(sarama.Client), nil
+}
+
+// GetClusterAdmin returns a sarama.ClusterAdmin backed by the pooled client for
+// key, dialing brokers with config and adding key to the pool on first use.
+func (p *Pool) GetClusterAdmin(brokers []string, key Key, config *sarama.Config) (sarama.ClusterAdmin, error) {
+	client, err := p.acquire(brokers, key, config)
+	if err != nil {
+		return nil, err
+	}
+	return sarama.NewClusterAdminFromClient(client.(sarama.Client))
+}
+
+// GetConsumerGroup returns a sarama.ConsumerGroup backed by the pooled client for
+// key, dialing brokers with config and adding key to the pool on first use.
+func (p *Pool) GetConsumerGroup(brokers []string, groupID string, key Key, config *sarama.Config) (sarama.ConsumerGroup, error) {
+	client, err := p.acquire(brokers, key, config)
+	if err != nil {
+		return nil, err
+	}
+	return sarama.NewConsumerGroupFromClient(groupID, client.(sarama.Client))
+}
+
+// GetProducer returns a sarama.SyncProducer backed by the pooled client for key,
+// dialing brokers with config and adding key to the pool on first use.
+func (p *Pool) GetProducer(brokers []string, key Key, config *sarama.Config) (sarama.SyncProducer, error) {
+	client, err := p.acquire(brokers, key, config)
+	if err != nil {
+		return nil, err
+	}
+	return sarama.NewSyncProducerFromClient(client.(sarama.Client))
+}
+
+// Release decrements key's reference count and closes the underlying client
+// once nothing references it anymore. Callers must pair every GetClient/
+// GetClusterAdmin/GetConsumerGroup/GetProducer call with a Release.
+func (p *Pool) Release(key Key) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	e, ok := p.entries[key]
+	if !ok {
+		return
+	}
+
+	e.refCount--
+	if e.refCount > 0 {
+		return
+	}
+
+	if err := e.client.Close(); err != nil {
+		p.logger.Error("Failed To Close Pooled Kafka Client", zap.Error(err))
+	}
+	delete(p.entries, key)
+}
+
+// acquire returns the pooled client for key, dialing brokers with config and
+// inserting a new entry if this is the first caller to reference key. If an
+// entry for key already exists, its client is returned as-is and config is
+// ignored - Key is derived from the same brokers/SASL user/TLS config the
+// caller built config from, so the two are expected to agree.
+func (p *Pool) acquire(brokers []string, key Key, config *sarama.Config) (pooledClient, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if e, ok := p.entries[key]; ok {
+		e.refCount++
+		return e.client, nil
+	}
+
+	client, err := newSaramaClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("creating pooled Kafka client: %w", err)
+	}
+
+	p.entries[key] = &entry{client: client, refCount: 1}
+	return client, nil
+}
+
+// Enabled reports whether the shared client pool is turned on, via the
+// ENABLE_SARAMA_CLIENT_POOL env var. Defaults to true; set to "false" to opt
+// back out to one Sarama client per subscription.
+func Enabled() bool {
+	return strings.ToLower(os.Getenv(EnableClientPoolEnvVar)) != "false"
+}
+
+// ConfigureLogging wires sarama.Logger / sarama.DebugLogger to logger when
+// ENABLE_SARAMA_LOGGER / ENABLE_SARAMA_DEBUG_LOGGER are set to "true", so
+// operators can turn on Sarama's own tracing during incident response without
+// a code change.
+func ConfigureLogging(logger *zap.Logger) {
+	if strings.ToLower(os.Getenv(EnableLoggerEnvVar)) == "true" {
+		sarama.Logger = zap.NewStdLog(logger.Named("sarama"))
+	}
+	if strings.ToLower(os.Getenv(EnableDebugLoggerEnvVar)) == "true" {
+		sarama.DebugLogger = zap.NewStdLog(logger.Named("sarama.debug"))
+	}
+}
+
+// contextKey is an unexported type to avoid collisions with context keys from
+// other packages.
+type contextKey struct{}
+
+// WithKafkaClientPool returns a copy of ctx carrying pool, retrievable via
+// FromContext. The dispatcher's NewController does not take a context: the
+// Pool reaches the Dispatcher only through dispatcher.NewDispatcher's pool
+// argument, so the owning process passes FromContext's result there.
+func WithKafkaClientPool(ctx context.Context, pool *Pool) context.Context {
+	return context.WithValue(ctx, contextKey{}, pool)
+}
+
+// FromContext returns the Pool previously attached via WithKafkaClientPool, or
+// nil if none was attached.
+func FromContext(ctx context.Context) *Pool {
+	pool, _ := ctx.Value(contextKey{}).(*Pool)
+	return pool
+}
@@ -0,0 +1,108 @@
+package clientpool
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"go.uber.org/zap"
+)
+
+// fakeClient is a minimal pooledClient fake, so acquire/Release's ref-counting
+// can be exercised without dialing a live broker.
+type fakeClient struct {
+	closed bool
+}
+
+func (f *fakeClient) Close() error {
+	f.closed = true
+	return nil
+}
+
+func withFakeClients(t *testing.T) *[]*fakeClient {
+	created := &[]*fakeClient{}
+	original := newSaramaClient
+	newSaramaClient = func(brokers []string, config *sarama.Config) (pooledClient, error) {
+		fc := &fakeClient{}
+		*created = append(*created, fc)
+		return fc, nil
+	}
+	t.Cleanup(func() { newSaramaClient = original })
+	return created
+}
+
+func TestPool_AcquireSharesClientForSameKey(t *testing.T) {
+	created := withFakeClients(t)
+	p := NewPool(zap.NewNop())
+	key := NewKey([]string{"broker:9092"}, "user", nil)
+
+	first, err := p.acquire([]string{"broker:9092"}, key, &sarama.Config{})
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	second, err := p.acquire([]string{"broker:9092"}, key, &sarama.Config{})
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	if first != second {
+		t.Error("acquire() dialed a second client for an already-pooled key")
+	}
+	if len(*created) != 1 {
+		t.Errorf("newSaramaClient called %d times, want 1", len(*created))
+	}
+	if p.entries[key].refCount != 2 {
+		t.Errorf("refCount = %d, want 2", p.entries[key].refCount)
+	}
+}
+
+func TestPool_AcquireDialsSeparatelyForDistinctKeys(t *testing.T) {
+	created := withFakeClients(t)
+	p := NewPool(zap.NewNop())
+	keyA := NewKey([]string{"broker:9092"}, "user-a", nil)
+	keyB := NewKey([]string{"broker:9092"}, "user-b", nil)
+
+	if _, err := p.acquire([]string{"broker:9092"}, keyA, &sarama.Config{}); err != nil {
+		t.Fatalf("acquire(keyA) error = %v", err)
+	}
+	if _, err := p.acquire([]string{"broker:9092"}, keyB, &sarama.Config{}); err != nil {
+		t.Fatalf("acquire(keyB) error = %v", err)
+	}
+
+	if len(*created) != 2 {
+		t.Errorf("newSaramaClient called %d times, want 2", len(*created))
+	}
+}
+
+func TestPool_ReleaseClosesClientOnceRefCountReachesZero(t *testing.T) {
+	created := withFakeClients(t)
+	p := NewPool(zap.NewNop())
+	key := NewKey([]string{"broker:9092"}, "user", nil)
+
+	if _, err := p.acquire([]string{"broker:9092"}, key, &sarama.Config{}); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if _, err := p.acquire([]string{"broker:9092"}, key, &sarama.Config{}); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	p.Release(key)
+	if (*created)[0].closed {
+		t.Error("Release() closed the client while a reference remained")
+	}
+	if _, ok := p.entries[key]; !ok {
+		t.Error("Release() removed the entry while a reference remained")
+	}
+
+	p.Release(key)
+	if !(*created)[0].closed {
+		t.Error("Release() did not close the client once refCount reached zero")
+	}
+	if _, ok := p.entries[key]; ok {
+		t.Error("Release() left the entry in the pool after refCount reached zero")
+	}
+}
+
+func TestPool_ReleaseUnknownKeyIsNoOp(t *testing.T) {
+	p := NewPool(zap.NewNop())
+	p.Release(NewKey([]string{"broker:9092"}, "user", nil))
+}
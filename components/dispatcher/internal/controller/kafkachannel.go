@@ -9,15 +9,23 @@ import (
 	"github.com/kyma-incubator/knative-kafka/components/controller/pkg/client/informers/externalversions/knativekafka/v1alpha1"
 	listers "github.com/kyma-incubator/knative-kafka/components/controller/pkg/client/listers/knativekafka/v1alpha1"
 	"github.com/kyma-incubator/knative-kafka/components/dispatcher/internal/dispatcher"
+	"github.com/kyma-incubator/knative-kafka/components/dispatcher/internal/eventpolicy"
+	"github.com/kyma-incubator/knative-kafka/components/dispatcher/internal/status"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	eventingduck "knative.dev/eventing/pkg/apis/duck/v1beta1"
+	eventingv1alpha1 "knative.dev/eventing/pkg/apis/eventing/v1alpha1"
+	eventpolicyinformers "knative.dev/eventing/pkg/client/informers/externalversions/eventing/v1alpha1"
+	eventpolicylisters "knative.dev/eventing/pkg/client/listers/eventing/v1alpha1"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	"reflect"
@@ -39,23 +47,33 @@ type Reconciler struct {
 	Logger               *zap.Logger
 	kafkachannelInformer cache.SharedIndexInformer
 	kafkachannelLister   listers.KafkaChannelLister
+	eventPolicyLister    eventpolicylisters.EventPolicyLister
 	impl                 *controller.Impl
 	Recorder             record.EventRecorder
 	KafkaClientSet       versioned.Interface
+	StatusProber         status.Prober
 }
 
 var _ controller.Reconciler = Reconciler{}
 
 // NewController initializes the controller and is called by the generated code.
-// Registers event handlers to enqueue events.
-func NewController(logger *zap.Logger, dispatcher *dispatcher.Dispatcher, kafkachannelInformer v1alpha1.KafkaChannelInformer, kubeClient kubernetes.Interface, kafkaClientSet versioned.Interface, stopChannel <-chan struct{}) *controller.Impl {
+// Registers event handlers to enqueue events. dispatcher is expected to have
+// already been constructed with the shared clientpool.Pool (via
+// dispatcher.NewDispatcher's pool argument, sourced from
+// clientpool.FromContext on the owning process's context) so its consumer
+// groups draw from the pool instead of each dialing its own Sarama client;
+// the reconciler itself only needs the already-built Dispatcher to push
+// subscription updates to.
+func NewController(logger *zap.Logger, dispatcher *dispatcher.Dispatcher, kafkachannelInformer v1alpha1.KafkaChannelInformer, podInformer coreinformers.PodInformer, eventPolicyInformer eventpolicyinformers.EventPolicyInformer, kubeClient kubernetes.Interface, kafkaClientSet versioned.Interface, stopChannel <-chan struct{}) *controller.Impl {
 
 	r := &Reconciler{
 		Logger:               logger,
 		dispatcher:           dispatcher,
 		kafkachannelInformer: kafkachannelInformer.Informer(),
 		kafkachannelLister:   kafkachannelInformer.Lister(),
+		eventPolicyLister:    eventPolicyInformer.Lister(),
 		KafkaClientSet:       kafkaClientSet,
+		StatusProber:         status.NewProber(logger, podInformer.Lister()),
 	}
 	r.impl = controller.NewImpl(r, r.Logger.Sugar(), ReconcilerName)
 
@@ -63,6 +81,19 @@ func NewController(logger *zap.Logger, dispatcher *dispatcher.Dispatcher, kafkac
 
 	// Watch for kafka channels.
 	kafkachannelInformer.Informer().AddEventHandler(controller.HandleAll(r.impl.Enqueue))
+
+	// Watch for EventPolicies, re-enqueuing every KafkaChannel they reference via
+	// Spec.To so a policy change is re-translated into the dispatcher's auth contract.
+	eventPolicyInformer.Informer().AddEventHandler(controller.HandleAll(func(obj interface{}) {
+		policy, ok := unwrapEventPolicy(obj)
+		if !ok {
+			return
+		}
+		for _, key := range referencedKafkaChannelKeys(policy) {
+			r.impl.EnqueueKey(key)
+		}
+	}))
+
 	logger.Debug("Creating event broadcaster")
 	eventBroadcaster := record.NewBroadcaster()
 	watches := []watch.Interface{
@@ -139,43 +170,159 @@ func (r Reconciler) reconcile(ctx context.Context, channel *kafkav1alpha1.KafkaC
 		return nil
 	}
 
-	subscriptions := make([]dispatcher.Subscription, 0)
-	for _, subscriber := range channel.Spec.Subscribers {
-		groupId := fmt.Sprintf("kafka.%s", subscriber.UID)
-		subscriptions = append(subscriptions, dispatcher.Subscription{URI: subscriber.SubscriberURI, GroupId: groupId})
-		r.Logger.Debug("Adding Subscriber, Consumer Group", zap.String("groupId", groupId), zap.String("URI", subscriber.SubscriberURI.String()))
-	}
+	authPolicies, authErr := r.resolveAuthPolicies(channel)
 
-	failedSubscriptions := r.dispatcher.UpdateSubscriptions(subscriptions)
+	// Fail closed on readiness, not on delivery: an unresolved EventPolicy means we
+	// cannot yet prove who is allowed to receive events, so withhold the "ready"
+	// signal below. But calling UpdateSubscriptions with an emptied subscription set
+	// would cancel every already-running consumer group on the channel, not just the
+	// new/affected one - so on authErr, skip the call entirely and keep serving
+	// whatever subscriptions the dispatcher already has.
+	var failedSubscriptions map[dispatcher.Subscription]error
+	if authErr != nil {
+		r.Logger.Error("Failed To Resolve EventPolicy Authorization For KafkaChannel; Withholding Readiness, Keeping Last-Known-Good Subscriptions", zap.Error(authErr))
+	} else {
+		var subscriptions []dispatcher.Subscription
+		authPoliciesByGroupId := make(map[string][]eventpolicy.AuthPolicy)
+		for _, subscriber := range channel.Spec.Subscribers {
+			groupId := dispatcher.GroupID(subscriber.UID)
+			subscriptions = append(subscriptions, dispatcher.Subscription{URI: subscriber.SubscriberURI, GroupId: groupId})
+			authPoliciesByGroupId[groupId] = authPolicies
+			r.Logger.Debug("Adding Subscriber, Consumer Group", zap.String("groupId", groupId), zap.String("URI", subscriber.SubscriberURI.String()))
+		}
+		failedSubscriptions = r.dispatcher.UpdateSubscriptions(ctx, subscriptions, authPoliciesByGroupId)
+	}
 
-	channel.Status.SubscribableStatus = r.createSubscribableStatus(channel.Spec.SubscribableSpec, failedSubscriptions)
+	subscribableStatus, allConfirmed := r.createSubscribableStatus(ctx, channel, failedSubscriptions, authErr)
+	channel.Status.SubscribableStatus = subscribableStatus
+	if authErr != nil {
+		return fmt.Errorf("resolving EventPolicy authorization: %w", authErr)
+	}
 	if len(failedSubscriptions) > 0 {
 		r.Logger.Error("Some kafka subscriptions failed to subscribe")
 		return fmt.Errorf("Some kafka subscriptions failed to subscribe")
 	}
+	if !allConfirmed {
+		return fmt.Errorf("Waiting on dispatcher pods to confirm subscriber readiness")
+	}
 	return nil
 }
 
-// Create The SubscribableStatus Block Based On The Updated Subscriptions
-func (r *Reconciler) createSubscribableStatus(subscribable eventingduck.SubscribableSpec, failedSubscriptions map[dispatcher.Subscription]error) eventingduck.SubscribableStatus {
-	subscriberStatus := make([]eventingduck.SubscriberStatus, 0)
+// Create The SubscribableStatus Block Based On The Updated Subscriptions, Probing The
+// Dispatcher Pods To Confirm Consumer Group Membership Before Advertising Readiness.
+// Returns false if any subscriber is not yet confirmed ready or removed, so the
+// caller can requeue the key and re-probe on the next reconcile.
+func (r *Reconciler) createSubscribableStatus(ctx context.Context, channel *kafkav1alpha1.KafkaChannel, failedSubscriptions map[dispatcher.Subscription]error, authErr error) (eventingduck.SubscribableStatus, bool) {
+	channelKey := types.NamespacedName{Namespace: channel.Namespace, Name: channel.Name}
+	subscribable := channel.Spec.SubscribableSpec
+
+	allConfirmed := true
+	desired := make(map[types.UID]bool, len(subscribable.Subscribers))
+	subscriberStatus := make([]eventingduck.SubscriberStatus, 0, len(subscribable.Subscribers))
+
 	for _, sub := range subscribable.Subscribers {
-		status := eventingduck.SubscriberStatus{
+		desired[sub.UID] = true
+		subStatus := eventingduck.SubscriberStatus{
 			UID:                sub.UID,
 			ObservedGeneration: sub.Generation,
-			Ready:              corev1.ConditionTrue,
+			Ready:              corev1.ConditionUnknown,
 		}
-		groupId := fmt.Sprintf("kafka.%s", sub.UID)
+
+		groupId := dispatcher.GroupID(sub.UID)
 		subscription := dispatcher.Subscription{URI: sub.SubscriberURI, GroupId: groupId}
-		if err, ok := failedSubscriptions[subscription]; ok {
-			status.Ready = corev1.ConditionFalse
-			status.Message = err.Error()
+		if authErr != nil {
+			subStatus.Ready = corev1.ConditionFalse
+			subStatus.Message = "AuthResolutionFailed: " + authErr.Error()
+		} else if err, failed := failedSubscriptions[subscription]; failed {
+			subStatus.Ready = corev1.ConditionFalse
+			subStatus.Message = err.Error()
+		} else if ready, err := r.StatusProber.IsReady(ctx, channelKey, status.SubscriberKey{Channel: channelKey, UID: sub.UID}); err != nil {
+			r.Logger.Error("Failed To Probe Dispatcher Pods For Subscriber Readiness", zap.Error(err), zap.String("uid", string(sub.UID)))
+			subStatus.Message = "ProbeFailed: " + err.Error()
+		} else if ready {
+			subStatus.Ready = corev1.ConditionTrue
+		} else {
+			subStatus.Message = "ProbeFailed: waiting for dispatcher pods to confirm consumer group readiness"
 		}
-		subscriberStatus = append(subscriberStatus, status)
+
+		if subStatus.Ready != corev1.ConditionTrue {
+			allConfirmed = false
+		}
+		subscriberStatus = append(subscriberStatus, subStatus)
 	}
-	return eventingduck.SubscribableStatus{
-		Subscribers: subscriberStatus,
+
+	// Subscribers no longer in the spec stay in the status as Unknown, with cleanup
+	// blocking the requeue, until the dispatcher pods confirm their consumer groups
+	// have actually been torn down.
+	for _, prev := range channel.Status.SubscribableStatus.Subscribers {
+		if desired[prev.UID] {
+			continue
+		}
+		unsubscribed, err := r.StatusProber.IsUnsubscribed(ctx, channelKey, status.SubscriberKey{Channel: channelKey, UID: prev.UID})
+		if err != nil {
+			r.Logger.Error("Failed To Probe Dispatcher Pods For Subscriber Removal", zap.Error(err), zap.String("uid", string(prev.UID)))
+		}
+		if unsubscribed {
+			continue
+		}
+		allConfirmed = false
+		subscriberStatus = append(subscriberStatus, eventingduck.SubscriberStatus{
+			UID:                prev.UID,
+			ObservedGeneration: prev.ObservedGeneration,
+			Ready:              corev1.ConditionUnknown,
+			Message:            "ProbeFailed: waiting for dispatcher pods to confirm consumer group removal",
+		})
+	}
+
+	return eventingduck.SubscribableStatus{Subscribers: subscriberStatus}, allConfirmed
+}
+
+// resolveAuthPolicies translates the EventPolicies referencing channel into the
+// AuthPolicy contract the dispatcher enforces before delivering to a subscriber.
+func (r Reconciler) resolveAuthPolicies(channel *kafkav1alpha1.KafkaChannel) ([]eventpolicy.AuthPolicy, error) {
+	policies, err := r.eventPolicyLister.EventPolicies(channel.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("listing EventPolicies: %w", err)
+	}
+
+	referencing := make([]*eventingv1alpha1.EventPolicy, 0, len(policies))
+	for _, policy := range policies {
+		for _, key := range referencedKafkaChannelKeys(policy) {
+			if key.Name == channel.Name {
+				referencing = append(referencing, policy)
+				break
+			}
+		}
+	}
+
+	return eventpolicy.Resolve(referencing)
+}
+
+// unwrapEventPolicy extracts an *eventingv1alpha1.EventPolicy from an informer event
+// object, unwrapping a cache.DeletedFinalStateUnknown tombstone first if needed - a
+// delete event can be delivered as one when the watch missed it and it's only
+// recovered via relist, and without unwrapping the type assertion fails silently,
+// leaving the EventPolicy's KafkaChannels never re-enqueued for that deletion.
+func unwrapEventPolicy(obj interface{}) (*eventingv1alpha1.EventPolicy, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	policy, ok := obj.(*eventingv1alpha1.EventPolicy)
+	return policy, ok
+}
+
+// referencedKafkaChannelKeys returns the namespace/name of every KafkaChannel that
+// policy's Spec.To resolves to, so the reconciler can re-enqueue affected channels
+// when the policy changes.
+func referencedKafkaChannelKeys(policy *eventingv1alpha1.EventPolicy) []types.NamespacedName {
+	keys := make([]types.NamespacedName, 0, len(policy.Spec.To))
+	for _, to := range policy.Spec.To {
+		if to.Ref == nil || to.Ref.Kind != "KafkaChannel" {
+			continue
+		}
+		keys = append(keys, types.NamespacedName{Namespace: policy.Namespace, Name: to.Ref.Name})
 	}
+	return keys
 }
 
 func (r *Reconciler) updateStatus(ctx context.Context, desired *kafkav1alpha1.KafkaChannel) (*kafkav1alpha1.KafkaChannel, error) {
@@ -0,0 +1,464 @@
+// Package dispatcher owns a single KafkaChannel's Kafka consumer groups - one per
+// subscriber, each forwarding consumed messages to the subscriber's URI after
+// checking the subscriber's resolved EventPolicy authorization - and serves the
+// dispatcher pod's own status endpoint so the controller's status.Prober can
+// confirm a consumer group has actually joined (or torn down) before the
+// corresponding KafkaChannel subscriber status changes.
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/kyma-incubator/knative-kafka/components/common/pkg/kafka/clientpool"
+	"github.com/kyma-incubator/knative-kafka/components/dispatcher/internal/eventpolicy"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// StatusPath is served by every dispatcher pod so the controller's
+	// status.Prober can confirm a subscriber's consumer group has joined
+	// (state=ready) or been torn down (state=removed).
+	StatusPath = "/healthz/subscriptions"
+
+	// StatusPort is the port StatusPath is served on; status.Prober dials pods
+	// on this port.
+	StatusPort = 8081
+)
+
+// GroupID returns the Kafka consumer group id for a subscriber, given its UID.
+// Shared by the controller (to build Subscriptions and probe keys) and the
+// dispatcher (to correlate a consumer group with its Subscription) so the two
+// never drift apart.
+func GroupID(uid types.UID) string {
+	return fmt.Sprintf("kafka.%s", uid)
+}
+
+// Subscription is a single subscriber's delivery target and consumer group. It
+// is used as a map key, so it intentionally carries no slice/map fields -
+// resolved EventPolicy authorization is threaded through UpdateSubscriptions
+// separately, keyed by GroupId, rather than folded into this struct.
+type Subscription struct {
+	URI     *url.URL
+	GroupId string
+}
+
+// subscriberState tracks the running consumer group backing a single Subscription.
+type subscriberState struct {
+	subscription Subscription
+	cancel       context.CancelFunc
+	offsets      *offsetTracker
+}
+
+// offsetTracker tracks, for a single subscription, whether its consumer group
+// has read each partition up to the high-water mark captured when the
+// subscription was added - so a subscriber isn't reported ready merely
+// because its consumer group joined and was assigned partitions, before it
+// had actually read anything.
+type offsetTracker struct {
+	target   map[int32]int64
+	caughtUp map[int32]bool
+}
+
+// newOffsetTracker returns an offsetTracker for target, the high-water mark of
+// every partition of the topic at the moment the subscription was added. A
+// topic with no partitions is trivially ready, since there is nothing to
+// catch up to.
+func newOffsetTracker(target map[int32]int64) *offsetTracker {
+	return &offsetTracker{
+		target:   target,
+		caughtUp: make(map[int32]bool, len(target)),
+	}
+}
+
+// advance records that partition has been read up to offset - a claim's
+// InitialOffset() once assigned, or a delivered message's Offset+1 - and
+// reports whether every tracked partition has now caught up. A partition
+// missing from target is ignored: HWMs are captured for every partition that
+// existed at subscribe time, so this only happens for one added to the topic
+// afterward, which was never counted.
+func (t *offsetTracker) advance(partition int32, offset int64) bool {
+	if target, ok := t.target[partition]; ok && offset >= target {
+		t.caughtUp[partition] = true
+	}
+	return t.ready()
+}
+
+// ready reports whether every partition in target has caught up.
+func (t *offsetTracker) ready() bool {
+	return len(t.caughtUp) == len(t.target)
+}
+
+// Dispatcher owns the Kafka consumer groups for a single KafkaChannel's
+// subscribers, and serves their probe-able status over HTTP.
+type Dispatcher struct {
+	ChannelKey string
+
+	logger     *zap.Logger
+	topic      string
+	brokers    []string
+	config     *sarama.Config
+	clientPool *clientpool.Pool
+	httpClient *http.Client
+
+	mutex        sync.RWMutex
+	states       map[string]*subscriberState         // keyed by GroupId
+	authPolicies map[string][]eventpolicy.AuthPolicy // keyed by GroupId
+}
+
+// NewDispatcher constructs a Dispatcher for the KafkaChannel identified by
+// channelKey, consuming topic from brokers, and starts its status endpoint
+// listening on StatusPort. If pool is non-nil and clientpool.Enabled(), consumer
+// groups are acquired from pool instead of each dialing its own Sarama client.
+func NewDispatcher(logger *zap.Logger, channelKey string, topic string, brokers []string, config *sarama.Config, pool *clientpool.Pool) *Dispatcher {
+	clientpool.ConfigureLogging(logger)
+
+	d := &Dispatcher{
+		ChannelKey:   channelKey,
+		logger:       logger,
+		topic:        topic,
+		brokers:      brokers,
+		config:       config,
+		clientPool:   pool,
+		httpClient:   &http.Client{},
+		states:       make(map[string]*subscriberState),
+		authPolicies: make(map[string][]eventpolicy.AuthPolicy),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(StatusPath, d.handleStatus)
+	addr := fmt.Sprintf(":%d", StatusPort)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("Status Endpoint Stopped", zap.Error(err), zap.String("addr", addr))
+		}
+	}()
+
+	return d
+}
+
+// handleStatus answers status.Prober's probes: it reports 200 if the consumer
+// group for the uid query param has reached the requested state ("ready" or
+// "removed"), 404 otherwise.
+func (d *Dispatcher) handleStatus(w http.ResponseWriter, r *http.Request) {
+	uid := types.UID(r.URL.Query().Get("uid"))
+	state := r.URL.Query().Get("state")
+	if uid == "" {
+		http.Error(w, "missing uid query param", http.StatusBadRequest)
+		return
+	}
+
+	groupId := GroupID(uid)
+
+	d.mutex.RLock()
+	st, subscribed := d.states[groupId]
+	d.mutex.RUnlock()
+
+	var ok bool
+	switch state {
+	case "ready":
+		ok = subscribed && st.offsets.ready()
+	case "removed":
+		ok = !subscribed
+	default:
+		http.Error(w, "state must be \"ready\" or \"removed\"", http.StatusBadRequest)
+		return
+	}
+
+	if !ok {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// startingSubscription is a toStart Subscription whose consumer group has
+// already been dialed and high-water marks captured, waiting only to be
+// installed into Dispatcher.states under the lock.
+type startingSubscription struct {
+	groupId       string
+	consumerGroup sarama.ConsumerGroup
+	groupCtx      context.Context
+	state         *subscriberState
+}
+
+// UpdateSubscriptions reconciles the running consumer groups against
+// subscriptions, starting consumer groups for new or changed subscribers and
+// stopping ones no longer desired. authPolicies is the EventPolicy
+// authorization contract to enforce before delivery, keyed by GroupId. It
+// returns the subset of subscriptions whose consumer group failed to start.
+func (d *Dispatcher) UpdateSubscriptions(ctx context.Context, subscriptions []Subscription, authPolicies map[string][]eventpolicy.AuthPolicy) map[Subscription]error {
+	d.mutex.Lock()
+	d.authPolicies = authPolicies
+
+	desired := make(map[string]Subscription, len(subscriptions))
+	for _, sub := range subscriptions {
+		desired[sub.GroupId] = sub
+	}
+
+	toCancel, toStart := diffSubscriptions(d.states, desired)
+
+	for _, groupId := range toCancel {
+		d.states[groupId].cancel()
+		delete(d.states, groupId)
+	}
+	d.mutex.Unlock()
+
+	failed := make(map[Subscription]error)
+
+	// Capturing high-water marks and dialing/joining new consumer groups both
+	// round-trip to the broker, so both run with d.mutex released - holding it
+	// across them would block handleStatus (and so every status.Prober probe in
+	// flight) for as long as this reconcile's network calls take, turning a
+	// subscription change into a window where every probe for this channel times
+	// out. The lock is only reacquired below to install the results.
+
+	// Every new subscription targets the same topic, so its high-water marks are
+	// captured once up front rather than once per subscription - they're the
+	// snapshot each subscription's consumer group must individually catch up to,
+	// not a per-subscription quantity.
+	var targetOffsets map[int32]int64
+	if len(toStart) > 0 {
+		var err error
+		targetOffsets, err = d.partitionHWMs()
+		if err != nil {
+			for _, sub := range toStart {
+				failed[sub] = fmt.Errorf("capturing high-water marks for topic %q: %w", d.topic, err)
+			}
+			return failed
+		}
+	}
+
+	starting := make([]startingSubscription, 0, len(toStart))
+	for _, sub := range toStart {
+		consumerGroup, err := d.consumerGroupFor(sub.GroupId)
+		if err != nil {
+			failed[sub] = fmt.Errorf("creating consumer group %q: %w", sub.GroupId, err)
+			continue
+		}
+
+		groupCtx, cancel := context.WithCancel(ctx)
+		st := &subscriberState{subscription: sub, cancel: cancel, offsets: newOffsetTracker(targetOffsets)}
+		starting = append(starting, startingSubscription{groupId: sub.GroupId, consumerGroup: consumerGroup, groupCtx: groupCtx, state: st})
+	}
+
+	d.mutex.Lock()
+	for _, s := range starting {
+		d.states[s.groupId] = s.state
+	}
+	d.mutex.Unlock()
+
+	for _, s := range starting {
+		go d.consume(s.groupCtx, s.consumerGroup, s.state)
+	}
+
+	return failed
+}
+
+// partitionHWMs returns the high-water mark offset of every partition of
+// d.topic, so a just-added subscription's offsetTracker has a fixed target to
+// catch up to - one captured at subscribe time, rather than a live-tailing
+// target that a slow or heavily-loaded consumer group could chase forever.
+func (d *Dispatcher) partitionHWMs() (map[int32]int64, error) {
+	client, release, err := d.kafkaClient()
+	if err != nil {
+		return nil, fmt.Errorf("dialing client to capture high-water marks: %w", err)
+	}
+	defer release()
+
+	partitions, err := client.Partitions(d.topic)
+	if err != nil {
+		return nil, fmt.Errorf("listing partitions for topic %q: %w", d.topic, err)
+	}
+
+	hwms := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		offset, err := client.GetOffset(d.topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, fmt.Errorf("getting high-water mark for topic %q partition %d: %w", d.topic, partition, err)
+		}
+		hwms[partition] = offset
+	}
+	return hwms, nil
+}
+
+// kafkaClient returns a sarama.Client for d.brokers/d.config, drawing it from
+// d.clientPool when one is configured and enabled - mirroring
+// consumerGroupFor's pattern - so capturing high-water marks doesn't dial yet
+// another raw connection beyond whatever the pool already manages on this
+// dispatcher's behalf. The returned release func must be called once the
+// client is no longer needed.
+func (d *Dispatcher) kafkaClient() (sarama.Client, func(), error) {
+	if d.clientPool != nil && clientpool.Enabled() {
+		key := d.clientPoolKey()
+		client, err := d.clientPool.GetClient(d.brokers, key, d.config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, func() { d.clientPool.Release(key) }, nil
+	}
+
+	client, err := sarama.NewClient(d.brokers, d.config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, func() {
+		if closeErr := client.Close(); closeErr != nil {
+			d.logger.Error("Failed To Close Kafka Client", zap.Error(closeErr))
+		}
+	}, nil
+}
+
+// diffSubscriptions compares the consumer groups already running in states
+// against desired (keyed by GroupId) and reports the groupIds whose consumer
+// group should be cancelled - no longer desired, or desired against a
+// different subscriber URI - and the Subscriptions whose consumer group
+// should be (re)started. A Subscription already running against the same URI
+// appears in neither list. Callers must hold the Dispatcher's mutex.
+func diffSubscriptions(states map[string]*subscriberState, desired map[string]Subscription) (toCancel []string, toStart []Subscription) {
+	for groupId, st := range states {
+		sub, ok := desired[groupId]
+		if !ok || !sameURI(st.subscription.URI, sub.URI) {
+			toCancel = append(toCancel, groupId)
+		}
+	}
+
+	for groupId, sub := range desired {
+		if st, ok := states[groupId]; ok && sameURI(st.subscription.URI, sub.URI) {
+			continue
+		}
+		toStart = append(toStart, sub)
+	}
+
+	return toCancel, toStart
+}
+
+func sameURI(a, b *url.URL) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// consumerGroupFor returns a sarama.ConsumerGroup for groupId, drawing its
+// underlying client from d.clientPool when one is configured and enabled, so
+// that many subscriptions against the same cluster share one set of
+// connections instead of each dialing its own.
+func (d *Dispatcher) consumerGroupFor(groupId string) (sarama.ConsumerGroup, error) {
+	if d.clientPool != nil && clientpool.Enabled() {
+		return d.clientPool.GetConsumerGroup(d.brokers, groupId, d.clientPoolKey(), d.config)
+	}
+	return sarama.NewConsumerGroup(d.brokers, groupId, d.config)
+}
+
+func (d *Dispatcher) clientPoolKey() clientpool.Key {
+	var saslUser string
+	if d.config.Net.SASL.Enable {
+		saslUser = d.config.Net.SASL.User
+	}
+	return clientpool.NewKey(d.brokers, saslUser, d.config.Net.TLS.Config)
+}
+
+// consume runs consumerGroup's join/rebalance loop against d.topic until ctx is
+// cancelled, releasing the pooled client (if any) once consumption stops.
+func (d *Dispatcher) consume(ctx context.Context, consumerGroup sarama.ConsumerGroup, st *subscriberState) {
+	defer func() {
+		if err := consumerGroup.Close(); err != nil {
+			d.logger.Error("Failed To Close Consumer Group", zap.Error(err), zap.String("groupId", st.subscription.GroupId))
+		}
+		if d.clientPool != nil && clientpool.Enabled() {
+			d.clientPool.Release(d.clientPoolKey())
+		}
+	}()
+
+	handler := &consumerHandler{dispatcher: d, subscription: st.subscription}
+	for ctx.Err() == nil {
+		if err := consumerGroup.Consume(ctx, []string{d.topic}, handler); err != nil && ctx.Err() == nil {
+			d.logger.Error("Consumer Group Session Error", zap.Error(err), zap.String("groupId", st.subscription.GroupId))
+		}
+	}
+}
+
+// deliver enforces the subscriber's resolved EventPolicy authorization and, if
+// authorized, POSTs msg to the subscriber's URI.
+func (d *Dispatcher) deliver(ctx context.Context, sub Subscription, msg *sarama.ConsumerMessage) error {
+	d.mutex.RLock()
+	policies := d.authPolicies[sub.GroupId]
+	d.mutex.RUnlock()
+
+	if !eventpolicy.Authorized(policies, msg.Headers) {
+		return fmt.Errorf("delivery to %s rejected by EventPolicy authorization", sub.URI)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URI.String(), bytes.NewReader(msg.Value))
+	if err != nil {
+		return fmt.Errorf("building request for subscriber %s: %w", sub.URI, err)
+	}
+	for _, header := range msg.Headers {
+		req.Header.Set(string(header.Key), string(header.Value))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("delivering to subscriber %s: %w", sub.URI, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("subscriber %s returned status %d", sub.URI, resp.StatusCode)
+	}
+	return nil
+}
+
+// consumerHandler adapts a Subscription's delivery into a sarama.ConsumerGroupHandler.
+type consumerHandler struct {
+	dispatcher   *Dispatcher
+	subscription Subscription
+}
+
+// Setup is a no-op: readiness is driven off actually consumed offsets (see
+// ConsumeClaim and advance), not off the group merely joining and being
+// assigned partitions.
+func (h *consumerHandler) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *consumerHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *consumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	// A partition whose InitialOffset already reaches the captured high-water
+	// mark has nothing left to deliver, so it catches up here rather than
+	// waiting on a message that may never arrive.
+	h.advance(claim.Partition(), claim.InitialOffset())
+
+	for msg := range claim.Messages() {
+		if err := h.dispatcher.deliver(session.Context(), h.subscription, msg); err != nil {
+			h.dispatcher.logger.Error("Failed To Deliver Message", zap.Error(err), zap.String("groupId", h.subscription.GroupId))
+			continue
+		}
+		session.MarkMessage(msg, "")
+		h.advance(msg.Partition, msg.Offset+1)
+	}
+	return nil
+}
+
+// advance records that partition has been read up to offset against this
+// subscription's offsetTracker, so the "ready" probe status.Prober polls only
+// reports true once every partition has caught up to the high-water mark
+// captured when the subscription was added.
+func (h *consumerHandler) advance(partition int32, offset int64) {
+	h.dispatcher.mutex.Lock()
+	defer h.dispatcher.mutex.Unlock()
+
+	if st, ok := h.dispatcher.states[h.subscription.GroupId]; ok {
+		st.offsets.advance(partition, offset)
+	}
+}
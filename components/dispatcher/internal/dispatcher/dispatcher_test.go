@@ -0,0 +1,171 @@
+package dispatcher
+
+import (
+	"net/url"
+	"sort"
+	"testing"
+)
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("url.Parse(%q) error = %v", raw, err)
+	}
+	return u
+}
+
+func TestDiffSubscriptions(t *testing.T) {
+	keptURI := "http://kept.default.svc.cluster.local"
+	replacedOldURI := "http://replaced-old.default.svc.cluster.local"
+	replacedNewURI := "http://replaced-new.default.svc.cluster.local"
+
+	t.Run("unchanged subscription is neither cancelled nor started", func(t *testing.T) {
+		states := map[string]*subscriberState{
+			"kept": {subscription: Subscription{URI: mustURL(t, keptURI), GroupId: "kept"}},
+		}
+		desired := map[string]Subscription{
+			"kept": {URI: mustURL(t, keptURI), GroupId: "kept"},
+		}
+
+		toCancel, toStart := diffSubscriptions(states, desired)
+		if len(toCancel) != 0 {
+			t.Errorf("toCancel = %v, want empty", toCancel)
+		}
+		if len(toStart) != 0 {
+			t.Errorf("toStart = %v, want empty", toStart)
+		}
+	})
+
+	t.Run("new subscription is started, not cancelled", func(t *testing.T) {
+		states := map[string]*subscriberState{}
+		desired := map[string]Subscription{
+			"added": {URI: mustURL(t, keptURI), GroupId: "added"},
+		}
+
+		toCancel, toStart := diffSubscriptions(states, desired)
+		if len(toCancel) != 0 {
+			t.Errorf("toCancel = %v, want empty", toCancel)
+		}
+		if len(toStart) != 1 || toStart[0].GroupId != "added" {
+			t.Errorf("toStart = %v, want [added]", toStart)
+		}
+	})
+
+	t.Run("removed subscription is cancelled, not started", func(t *testing.T) {
+		states := map[string]*subscriberState{
+			"removed": {subscription: Subscription{URI: mustURL(t, keptURI), GroupId: "removed"}},
+		}
+		desired := map[string]Subscription{}
+
+		toCancel, toStart := diffSubscriptions(states, desired)
+		if len(toCancel) != 1 || toCancel[0] != "removed" {
+			t.Errorf("toCancel = %v, want [removed]", toCancel)
+		}
+		if len(toStart) != 0 {
+			t.Errorf("toStart = %v, want empty", toStart)
+		}
+	})
+
+	t.Run("changed URI cancels the old consumer group and starts a new one", func(t *testing.T) {
+		states := map[string]*subscriberState{
+			"replaced": {subscription: Subscription{URI: mustURL(t, replacedOldURI), GroupId: "replaced"}},
+		}
+		desired := map[string]Subscription{
+			"replaced": {URI: mustURL(t, replacedNewURI), GroupId: "replaced"},
+		}
+
+		toCancel, toStart := diffSubscriptions(states, desired)
+		if len(toCancel) != 1 || toCancel[0] != "replaced" {
+			t.Errorf("toCancel = %v, want [replaced]", toCancel)
+		}
+		if len(toStart) != 1 || toStart[0].URI.String() != replacedNewURI {
+			t.Errorf("toStart = %v, want [replaced -> %s]", toStart, replacedNewURI)
+		}
+	})
+
+	t.Run("mixed add, remove, replace, and keep in one call", func(t *testing.T) {
+		states := map[string]*subscriberState{
+			"kept":     {subscription: Subscription{URI: mustURL(t, keptURI), GroupId: "kept"}},
+			"removed":  {subscription: Subscription{URI: mustURL(t, keptURI), GroupId: "removed"}},
+			"replaced": {subscription: Subscription{URI: mustURL(t, replacedOldURI), GroupId: "replaced"}},
+		}
+		desired := map[string]Subscription{
+			"kept":     {URI: mustURL(t, keptURI), GroupId: "kept"},
+			"replaced": {URI: mustURL(t, replacedNewURI), GroupId: "replaced"},
+			"added":    {URI: mustURL(t, keptURI), GroupId: "added"},
+		}
+
+		toCancel, toStart := diffSubscriptions(states, desired)
+		sort.Strings(toCancel)
+		if want := []string{"removed", "replaced"}; !equalStrings(toCancel, want) {
+			t.Errorf("toCancel = %v, want %v", toCancel, want)
+		}
+
+		startedGroupIds := make([]string, 0, len(toStart))
+		for _, sub := range toStart {
+			startedGroupIds = append(startedGroupIds, sub.GroupId)
+		}
+		sort.Strings(startedGroupIds)
+		if want := []string{"added", "replaced"}; !equalStrings(startedGroupIds, want) {
+			t.Errorf("started groupIds = %v, want %v", startedGroupIds, want)
+		}
+	})
+}
+
+func TestOffsetTracker(t *testing.T) {
+	t.Run("topic with no partitions is trivially ready", func(t *testing.T) {
+		tracker := newOffsetTracker(map[int32]int64{})
+		if !tracker.ready() {
+			t.Error("ready() = false, want true for an empty target")
+		}
+	})
+
+	t.Run("not ready until every partition catches up", func(t *testing.T) {
+		tracker := newOffsetTracker(map[int32]int64{0: 10, 1: 5})
+
+		if tracker.advance(0, 10) {
+			t.Error("advance() = true after only one of two partitions caught up")
+		}
+		if !tracker.advance(1, 5) {
+			t.Error("advance() = false after both partitions caught up")
+		}
+		if !tracker.ready() {
+			t.Error("ready() = false after both partitions caught up")
+		}
+	})
+
+	t.Run("offset short of the high-water mark does not count as caught up", func(t *testing.T) {
+		tracker := newOffsetTracker(map[int32]int64{0: 10})
+
+		if tracker.advance(0, 9) {
+			t.Error("advance() = true for an offset short of the high-water mark")
+		}
+		if tracker.ready() {
+			t.Error("ready() = true before the high-water mark was reached")
+		}
+	})
+
+	t.Run("partition absent from target is ignored", func(t *testing.T) {
+		tracker := newOffsetTracker(map[int32]int64{0: 10})
+
+		if tracker.advance(1, 100) {
+			t.Error("advance() = true for an untracked partition, want target partition 0 still outstanding")
+		}
+		if tracker.ready() {
+			t.Error("ready() = true after only an untracked partition advanced")
+		}
+	})
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+package eventpolicy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+	eventingv1alpha1 "knative.dev/eventing/pkg/apis/eventing/v1alpha1"
+)
+
+// IdentityHeader is the Kafka record header the channel receiver attaches to a
+// message, carrying the caller identity that an AuthPolicy's From list is
+// checked against. Authorized trusts this header exactly as much as the
+// channel receiver that set it.
+const IdentityHeader = "ce-knativekafkaidentity"
+
+// ceHeaderPrefix is the Kafka binary content mode prefix the channel receiver
+// attaches CloudEvents attributes under (e.g. "type" rides on "ce_type"), per
+// the CloudEvents Kafka protocol binding.
+const ceHeaderPrefix = "ce_"
+
+// AuthPolicy is the resolved authorization contract enforced by the dispatcher
+// before delivering an event to a subscriber: the set of identities allowed to
+// deliver, plus the CloudEvents filters that gate the policy's applicability.
+type AuthPolicy struct {
+	// From lists the identities permitted under this policy: resolved OIDC
+	// service-account identities (e.g. "system:serviceaccount:ns:name") and/or
+	// CIDR blocks.
+	From []string
+
+	// Filters are the CloudEvents attribute filters that must match before From
+	// is consulted. A nil Filters applies the policy unconditionally.
+	Filters []eventingv1alpha1.SubscriptionsAPIFilter
+}
+
+// UnresolvedSubjectError is returned by Resolve when at least one EventPolicy
+// referencing the channel has a From entry that has not yet been resolved to a
+// concrete subject (e.g. a ServiceAccount ref still pending OIDC token binding).
+type UnresolvedSubjectError struct {
+	PolicyName string
+}
+
+func (e *UnresolvedSubjectError) Error() string {
+	return fmt.Sprintf("EventPolicy %q has an unresolved From subject", e.PolicyName)
+}
+
+// Resolve translates the EventPolicy objects that reference a KafkaChannel (via
+// their Spec.To) into the AuthPolicy list that should be enforced for a given
+// subscriber. It returns an UnresolvedSubjectError if any referencing policy's
+// status has not yet resolved all of its From subjects.
+func Resolve(policies []*eventingv1alpha1.EventPolicy) ([]AuthPolicy, error) {
+	authPolicies := make([]AuthPolicy, 0, len(policies))
+
+	for _, policy := range policies {
+		if !policy.Status.IsReady() {
+			return nil, &UnresolvedSubjectError{PolicyName: policy.Name}
+		}
+
+		from := make([]string, 0, len(policy.Status.From))
+		from = append(from, policy.Status.From...)
+
+		authPolicies = append(authPolicies, AuthPolicy{
+			From:    from,
+			Filters: policy.Spec.Filters,
+		})
+	}
+
+	return authPolicies, nil
+}
+
+// rejectedTotal counts deliveries rejected because no policy's From list
+// matched - the dispatcher's 403-equivalent, since a Kafka consumer has no
+// HTTP response to return a real 403 on. Exposed via RejectedTotal() rather
+// than a vendored metrics client, since none is vendored anywhere in this
+// module.
+var rejectedTotal int64
+
+// RejectedTotal returns the number of deliveries Authorized has rejected for
+// failing to match any policy's From list, since process start.
+func RejectedTotal() int64 {
+	return atomic.LoadInt64(&rejectedTotal)
+}
+
+// Authorized reports whether headers (the Kafka record headers carried by a
+// consumed message) satisfy at least one of policies. An empty policies list
+// means no EventPolicy references the channel, so delivery is unrestricted -
+// this matches the Knative EventPolicy convention that absence of any policy
+// imposes no restriction, while a non-empty list requires an explicit match.
+// A policy matches only if its Filters all match the message's CloudEvents
+// attributes (a nil/empty Filters applies unconditionally) AND at least one of
+// its From entries matches identity. Every rejection increments rejectedTotal.
+func Authorized(policies []AuthPolicy, headers []*sarama.RecordHeader) bool {
+	if len(policies) == 0 {
+		return true
+	}
+
+	identity := headerValue(headers, IdentityHeader)
+	if identity == "" {
+		atomic.AddInt64(&rejectedTotal, 1)
+		return false
+	}
+
+	attributes := ceAttributes(headers)
+	for _, policy := range policies {
+		if !filtersMatch(policy.Filters, attributes) {
+			continue
+		}
+		for _, from := range policy.From {
+			if fromMatches(from, identity) {
+				return true
+			}
+		}
+	}
+	atomic.AddInt64(&rejectedTotal, 1)
+	return false
+}
+
+// filtersMatch reports whether every filter in filters matches attributes, the
+// message's CloudEvents attributes - this is the "all filters in the list
+// apply" semantics Knative's SubscriptionsAPIFilter dialect uses elsewhere
+// (e.g. Trigger.Spec.Filters). A nil/empty filters list matches
+// unconditionally, so a policy with no Filters applies to every event its
+// From allows.
+func filtersMatch(filters []eventingv1alpha1.SubscriptionsAPIFilter, attributes map[string]string) bool {
+	for _, filter := range filters {
+		if !filterMatches(filter, attributes) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterMatches evaluates a single SubscriptionsAPIFilter against attributes.
+// Exact/Prefix/Suffix require every named attribute to match; All/Any/Not
+// compose nested filters with the usual boolean semantics. CESQL has no
+// evaluator vendored into this module (no CEL engine is available), so a
+// filter carrying one fails closed - rejected rather than matched
+// unconditionally, since silently ignoring a CESQL-scoped policy would
+// over-authorize exactly the class of event it was meant to exclude.
+func filterMatches(filter eventingv1alpha1.SubscriptionsAPIFilter, attributes map[string]string) bool {
+	if filter.CESQL != "" {
+		return false
+	}
+
+	for name, want := range filter.Exact {
+		if attributes[name] != want {
+			return false
+		}
+	}
+	for name, prefix := range filter.Prefix {
+		if !strings.HasPrefix(attributes[name], prefix) {
+			return false
+		}
+	}
+	for name, suffix := range filter.Suffix {
+		if !strings.HasSuffix(attributes[name], suffix) {
+			return false
+		}
+	}
+	if filter.Not != nil && filterMatches(*filter.Not, attributes) {
+		return false
+	}
+	for _, nested := range filter.All {
+		if !filterMatches(nested, attributes) {
+			return false
+		}
+	}
+	if len(filter.Any) > 0 {
+		matched := false
+		for _, nested := range filter.Any {
+			if filterMatches(nested, attributes) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ceAttributes extracts the CloudEvents attributes carried by a Kafka binary
+// content mode message - every header prefixed ceHeaderPrefix, keyed by the
+// attribute name with that prefix stripped - so filterMatches can evaluate a
+// SubscriptionsAPIFilter against them.
+func ceAttributes(headers []*sarama.RecordHeader) map[string]string {
+	attributes := make(map[string]string)
+	for _, header := range headers {
+		key := string(header.Key)
+		if !strings.HasPrefix(key, ceHeaderPrefix) {
+			continue
+		}
+		attributes[strings.TrimPrefix(key, ceHeaderPrefix)] = string(header.Value)
+	}
+	return attributes
+}
+
+// fromMatches reports whether identity satisfies a policy's From entry: if
+// from parses as a CIDR block, identity is matched as an IP address against
+// it; otherwise from is matched as an exact OIDC identity string.
+func fromMatches(from, identity string) bool {
+	if _, block, err := net.ParseCIDR(from); err == nil {
+		ip := net.ParseIP(identity)
+		return ip != nil && block.Contains(ip)
+	}
+	return from == identity
+}
+
+func headerValue(headers []*sarama.RecordHeader, key string) string {
+	for _, header := range headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+package eventpolicy
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	eventingv1alpha1 "knative.dev/eventing/pkg/apis/eventing/v1alpha1"
+)
+
+func headersWithIdentity(identity string) []*sarama.RecordHeader {
+	if identity == "" {
+		return nil
+	}
+	return []*sarama.RecordHeader{{Key: []byte(IdentityHeader), Value: []byte(identity)}}
+}
+
+func headersWithIdentityAndType(identity, ceType string) []*sarama.RecordHeader {
+	headers := headersWithIdentity(identity)
+	return append(headers, &sarama.RecordHeader{Key: []byte("ce_type"), Value: []byte(ceType)})
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []AuthPolicy
+		identity string
+		want     bool
+	}{
+		{
+			name:     "no policies means unrestricted",
+			policies: nil,
+			identity: "",
+			want:     true,
+		},
+		{
+			name:     "missing identity header is rejected",
+			policies: []AuthPolicy{{From: []string{"system:serviceaccount:ns:name"}}},
+			identity: "",
+			want:     false,
+		},
+		{
+			name:     "exact OIDC identity match",
+			policies: []AuthPolicy{{From: []string{"system:serviceaccount:ns:name"}}},
+			identity: "system:serviceaccount:ns:name",
+			want:     true,
+		},
+		{
+			name:     "non-matching OIDC identity is rejected",
+			policies: []AuthPolicy{{From: []string{"system:serviceaccount:ns:other"}}},
+			identity: "system:serviceaccount:ns:name",
+			want:     false,
+		},
+		{
+			name:     "identity IP within a CIDR block matches",
+			policies: []AuthPolicy{{From: []string{"10.0.0.0/24"}}},
+			identity: "10.0.0.42",
+			want:     true,
+		},
+		{
+			name:     "identity IP outside a CIDR block is rejected",
+			policies: []AuthPolicy{{From: []string{"10.0.0.0/24"}}},
+			identity: "10.0.1.42",
+			want:     false,
+		},
+		{
+			name: "matches the second policy when the first doesn't apply",
+			policies: []AuthPolicy{
+				{From: []string{"system:serviceaccount:ns:other"}},
+				{From: []string{"10.0.0.0/24"}},
+			},
+			identity: "10.0.0.1",
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := RejectedTotal()
+			got := Authorized(tt.policies, headersWithIdentity(tt.identity))
+			if got != tt.want {
+				t.Errorf("Authorized() = %v, want %v", got, tt.want)
+			}
+
+			wantRejectionBump := !tt.want
+			gotRejectionBump := RejectedTotal() > before
+			if gotRejectionBump != wantRejectionBump {
+				t.Errorf("RejectedTotal() bumped = %v, want %v", gotRejectionBump, wantRejectionBump)
+			}
+		})
+	}
+}
+
+func TestAuthorized_Filters(t *testing.T) {
+	const identity = "system:serviceaccount:ns:name"
+
+	tests := []struct {
+		name    string
+		filters []eventingv1alpha1.SubscriptionsAPIFilter
+		ceType  string
+		want    bool
+	}{
+		{
+			name:    "nil filters matches unconditionally",
+			filters: nil,
+			ceType:  "anything",
+			want:    true,
+		},
+		{
+			name:    "matching Exact attribute",
+			filters: []eventingv1alpha1.SubscriptionsAPIFilter{{Exact: map[string]string{"type": "widget.created"}}},
+			ceType:  "widget.created",
+			want:    true,
+		},
+		{
+			name:    "non-matching Exact attribute is rejected despite From matching",
+			filters: []eventingv1alpha1.SubscriptionsAPIFilter{{Exact: map[string]string{"type": "widget.created"}}},
+			ceType:  "widget.deleted",
+			want:    false,
+		},
+		{
+			name:    "matching Prefix attribute",
+			filters: []eventingv1alpha1.SubscriptionsAPIFilter{{Prefix: map[string]string{"type": "widget."}}},
+			ceType:  "widget.created",
+			want:    true,
+		},
+		{
+			name:    "non-matching Suffix attribute is rejected",
+			filters: []eventingv1alpha1.SubscriptionsAPIFilter{{Suffix: map[string]string{"type": ".deleted"}}},
+			ceType:  "widget.created",
+			want:    false,
+		},
+		{
+			name: "Any matches if at least one nested filter matches",
+			filters: []eventingv1alpha1.SubscriptionsAPIFilter{{Any: []eventingv1alpha1.SubscriptionsAPIFilter{
+				{Exact: map[string]string{"type": "widget.deleted"}},
+				{Exact: map[string]string{"type": "widget.created"}},
+			}}},
+			ceType: "widget.created",
+			want:   true,
+		},
+		{
+			name: "Not inverts a matching nested filter",
+			filters: []eventingv1alpha1.SubscriptionsAPIFilter{{Not: &eventingv1alpha1.SubscriptionsAPIFilter{
+				Exact: map[string]string{"type": "widget.created"},
+			}}},
+			ceType: "widget.created",
+			want:   false,
+		},
+		{
+			name:    "a CESQL filter fails closed rather than matching unconditionally",
+			filters: []eventingv1alpha1.SubscriptionsAPIFilter{{CESQL: "type = 'widget.created'"}},
+			ceType:  "widget.created",
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := []AuthPolicy{{From: []string{identity}, Filters: tt.filters}}
+			got := Authorized(policies, headersWithIdentityAndType(identity, tt.ceType))
+			if got != tt.want {
+				t.Errorf("Authorized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kyma-incubator/knative-kafka/components/dispatcher/internal/dispatcher"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+const (
+	// ChannelLabel is carried by dispatcher pods to identify the KafkaChannel they serve.
+	ChannelLabel = "messaging.knative.dev/channel"
+
+	// RoleLabel is carried by dispatcher pods to identify their role within the deployment.
+	RoleLabel = "messaging.knative.dev/role"
+
+	// DispatcherRole is the RoleLabel value carried by dispatcher pods.
+	DispatcherRole = "dispatcher"
+
+	probeTimeout = 2 * time.Second
+)
+
+// SubscriberKey identifies a single subscription being probed on a channel.
+type SubscriberKey struct {
+	Channel types.NamespacedName
+	UID     types.UID
+}
+
+// Prober confirms, by polling the dispatcher pods backing a KafkaChannel, that a
+// subscriber's Kafka consumer group has actually joined (or torn down) before
+// the control plane advertises the corresponding status change.
+type Prober interface {
+	// IsReady reports whether every dispatcher pod serving channel has confirmed
+	// that subscriber's consumer group has not just joined, but has also read
+	// every partition up to the high-water mark the dispatcher captured when the
+	// subscription was added (see dispatcher.offsetTracker) - so a subscriber
+	// isn't advertised ready while still behind on the backlog it was subscribed
+	// to consume.
+	IsReady(ctx context.Context, channel types.NamespacedName, subscriber SubscriberKey) (bool, error)
+
+	// IsUnsubscribed reports whether every dispatcher pod serving channel has
+	// confirmed that subscriber's consumer group has been torn down.
+	IsUnsubscribed(ctx context.Context, channel types.NamespacedName, subscriber SubscriberKey) (bool, error)
+}
+
+// prober is the default Prober implementation. It discovers dispatcher pods via
+// podLister and probes each one's status endpoint over plain HTTP.
+type prober struct {
+	logger     *zap.Logger
+	podLister  corelisters.PodLister
+	httpClient *http.Client
+}
+
+// NewProber returns a Prober that discovers dispatcher pods for a channel via
+// podLister, filtered by the ChannelLabel/RoleLabel pair, and probes them
+// individually over HTTP.
+func NewProber(logger *zap.Logger, podLister corelisters.PodLister) Prober {
+	return &prober{
+		logger:     logger,
+		podLister:  podLister,
+		httpClient: &http.Client{Timeout: probeTimeout},
+	}
+}
+
+func (p *prober) IsReady(ctx context.Context, channel types.NamespacedName, subscriber SubscriberKey) (bool, error) {
+	return p.probeAll(ctx, channel, subscriber, "ready")
+}
+
+func (p *prober) IsUnsubscribed(ctx context.Context, channel types.NamespacedName, subscriber SubscriberKey) (bool, error) {
+	pods, err := p.dispatcherPods(channel)
+	if err != nil {
+		return false, fmt.Errorf("listing dispatcher pods for channel %s: %w", channel, err)
+	}
+	if len(pods) == 0 {
+		// No dispatcher pods remain to still be subscribed, so cleanup is
+		// vacuously confirmed rather than blocking forever (e.g. once the
+		// dispatcher deployment is scaled to zero or torn down alongside the
+		// channel/namespace it served).
+		return true, nil
+	}
+	return p.probePods(ctx, pods, subscriber, "removed")
+}
+
+// probeAll queries every dispatcher pod serving channel and only reports true
+// if all of them report state for subscriber. An empty pod set is never ready.
+func (p *prober) probeAll(ctx context.Context, channel types.NamespacedName, subscriber SubscriberKey, state string) (bool, error) {
+	pods, err := p.dispatcherPods(channel)
+	if err != nil {
+		return false, fmt.Errorf("listing dispatcher pods for channel %s: %w", channel, err)
+	}
+	if len(pods) == 0 {
+		return false, nil
+	}
+	return p.probePods(ctx, pods, subscriber, state)
+}
+
+// probePods queries every pod for subscriber's state, only reporting true if
+// all of them confirm it.
+func (p *prober) probePods(ctx context.Context, pods []*corev1.Pod, subscriber SubscriberKey, state string) (bool, error) {
+	for _, pod := range pods {
+		if pod.Status.PodIP == "" {
+			return false, nil
+		}
+		ok, err := p.probePod(ctx, pod.Status.PodIP, subscriber, state)
+		if err != nil {
+			p.logger.Warn("Failed To Probe Dispatcher Pod", zap.String("pod", pod.Name), zap.Error(err))
+			return false, nil
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (p *prober) dispatcherPods(channel types.NamespacedName) ([]*corev1.Pod, error) {
+	selector := labels.SelectorFromSet(labels.Set{
+		ChannelLabel: channel.Name,
+		RoleLabel:    DispatcherRole,
+	})
+	return p.podLister.Pods(channel.Namespace).List(selector)
+}
+
+func (p *prober) probePod(ctx context.Context, podIP string, subscriber SubscriberKey, state string) (bool, error) {
+	url := fmt.Sprintf("http://%s:%d%s?uid=%s&state=%s", podIP, dispatcher.StatusPort, dispatcher.StatusPath, subscriber.UID, state)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK, nil
+}
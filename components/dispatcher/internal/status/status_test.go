@@ -0,0 +1,102 @@
+package status
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// fakePodNamespaceLister returns a fixed set of pods for List, regardless of
+// selector, so tests can control exactly which dispatcher pods a channel
+// "has" without a live API server.
+type fakePodNamespaceLister struct {
+	pods []*corev1.Pod
+}
+
+func (f fakePodNamespaceLister) List(labels.Selector) ([]*corev1.Pod, error) {
+	return f.pods, nil
+}
+
+func (f fakePodNamespaceLister) Get(name string) (*corev1.Pod, error) {
+	for _, pod := range f.pods {
+		if pod.Name == name {
+			return pod, nil
+		}
+	}
+	return nil, nil
+}
+
+// fakePodLister is a corelisters.PodLister backed by a fixed pod set, so the
+// prober can be exercised without a live API server. It ignores both the
+// namespace and the label selector, so dispatcherPods's filtering is not
+// exercised.
+type fakePodLister struct {
+	pods []*corev1.Pod
+}
+
+func (f fakePodLister) List(labels.Selector) ([]*corev1.Pod, error) {
+	return f.pods, nil
+}
+
+func (f fakePodLister) Pods(string) corelisters.PodNamespaceLister {
+	return fakePodNamespaceLister{pods: f.pods}
+}
+
+var _ corelisters.PodLister = fakePodLister{}
+
+func TestProber_EmptyPodSet(t *testing.T) {
+	channel := types.NamespacedName{Namespace: "ns", Name: "channel"}
+	subscriber := SubscriberKey{Channel: channel, UID: types.UID("sub-uid")}
+
+	p := NewProber(zap.NewNop(), fakePodLister{})
+
+	t.Run("IsReady is never true with no dispatcher pods", func(t *testing.T) {
+		ready, err := p.IsReady(context.Background(), channel, subscriber)
+		if err != nil {
+			t.Fatalf("IsReady() error = %v", err)
+		}
+		if ready {
+			t.Error("IsReady() = true with no dispatcher pods, want false")
+		}
+	})
+
+	t.Run("IsUnsubscribed is vacuously true with no dispatcher pods", func(t *testing.T) {
+		unsubscribed, err := p.IsUnsubscribed(context.Background(), channel, subscriber)
+		if err != nil {
+			t.Fatalf("IsUnsubscribed() error = %v", err)
+		}
+		if !unsubscribed {
+			t.Error("IsUnsubscribed() = false with no dispatcher pods, want true")
+		}
+	})
+}
+
+func TestProber_PodWithoutIPIsNeverReady(t *testing.T) {
+	channel := types.NamespacedName{Namespace: "ns", Name: "channel"}
+	subscriber := SubscriberKey{Channel: channel, UID: types.UID("sub-uid")}
+
+	pods := []*corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "dispatcher-0",
+			Labels: map[string]string{
+				ChannelLabel: channel.Name,
+				RoleLabel:    DispatcherRole,
+			},
+		},
+	}}
+	p := NewProber(zap.NewNop(), fakePodLister{pods: pods})
+
+	ready, err := p.IsReady(context.Background(), channel, subscriber)
+	if err != nil {
+		t.Fatalf("IsReady() error = %v", err)
+	}
+	if ready {
+		t.Error("IsReady() = true for a pod with no PodIP yet, want false")
+	}
+}